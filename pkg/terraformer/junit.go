@@ -0,0 +1,111 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// junitTestSuite is a minimal representation of the JUnit XML schema consumed by Jenkins, Tekton and GitLab.
+type junitTestSuite struct {
+	XMLName    xml.Name        `xml:"testsuite"`
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       float64         `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Detail  string `xml:",chardata"`
+}
+
+// writeJUnitReport renders the diagnostics collected from `terraform validate`/`terraform plan` into a JUnit XML
+// file at paths.JUnitReportPath. Diagnostics of severity "error" become failures, "warning" become skipped
+// testcases; if there are none and runErr is nil, a single passing testcase is emitted so consumers see a green
+// run. If runErr is set but no diagnostic of severity "error" accounts for it (e.g. a CLI/auth/crash failure
+// that never emitted a machine-readable diagnostic), a synthetic failing testcase is emitted instead, so the
+// report never goes green for a run that Run ultimately reported as failed. timestamp marks when the run
+// started; the terraform version is carried as a testsuite property instead of overloading the timestamp
+// attribute, so strict JUnit consumers can still parse it as an actual date-time.
+func (t *Terraformer) writeJUnitReport(terraformVersion string, timestamp time.Time, elapsed time.Duration, diagnostics []*structuredDiagnostic, runErr error) error {
+	suite := junitTestSuite{
+		Name:      "terraform validate",
+		Time:      elapsed.Seconds(),
+		Timestamp: timestamp.UTC().Format(time.RFC3339),
+		Properties: []junitProperty{
+			{Name: "terraform.version", Value: terraformVersion},
+		},
+	}
+
+	hasFailureDiagnostic := false
+	for _, d := range diagnostics {
+		suite.Tests++
+
+		name := d.Address
+		if name == "" {
+			name = "root module"
+		}
+
+		tc := junitTestCase{Name: name, ClassName: "terraform"}
+		switch d.Severity {
+		case "error":
+			suite.Failures++
+			hasFailureDiagnostic = true
+			tc.Failure = &junitMessage{Message: d.Summary, Detail: d.Detail}
+		case "warning":
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: d.Summary, Detail: d.Detail}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	switch {
+	case runErr != nil && !hasFailureDiagnostic:
+		suite.Tests++
+		suite.Failures++
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "root module",
+			ClassName: "terraform",
+			Failure:   &junitMessage{Message: "terraform command failed", Detail: runErr.Error()},
+		})
+	case len(diagnostics) == 0:
+		suite.Tests = 1
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      "root module",
+			ClassName: "terraform",
+		})
+	}
+
+	out, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal JUnit report: %w", err)
+	}
+
+	if err := ioutil.WriteFile(t.paths.JUnitReportPath, append([]byte(xml.Header), out...), 0644); err != nil {
+		return fmt.Errorf("could not write JUnit report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,15 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// defaultLogger returns the logr.Logger used by NewDefaultTerraformer.
+func defaultLogger() logr.Logger {
+	return zap.New(zap.UseDevMode(false))
+}
@@ -0,0 +1,120 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controllerutil"
+)
+
+// terraformOutput mirrors a single entry of the map produced by `terraform output -json`.
+type terraformOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value"`
+}
+
+func (t *Terraformer) getOutputsSecret(ctx context.Context) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := t.client.Get(ctx, types.NamespacedName{Namespace: t.config.Namespace, Name: t.config.OutputsSecretName}, secret); err != nil {
+		return nil, fmt.Errorf("could not fetch outputs secret: %w", err)
+	}
+	return secret, nil
+}
+
+func (t *Terraformer) getOutputsConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := t.client.Get(ctx, types.NamespacedName{Namespace: t.config.Namespace, Name: t.config.OutputsConfigMapName}, cm); err != nil {
+		return nil, fmt.Errorf("could not fetch outputs configmap: %w", err)
+	}
+	return cm, nil
+}
+
+// persistOutputs parses the result of `terraform output -json` and stores every output, keyed by name, in the
+// outputs Secret (creating it if necessary), so sensitive outputs always end up somewhere access-controlled. If
+// Config.OutputsConfigMapName is set, the non-sensitive outputs are additionally mirrored into that ConfigMap.
+func (t *Terraformer) persistOutputs(ctx context.Context, raw []byte) error {
+	var outputs map[string]terraformOutput
+	if err := json.Unmarshal(raw, &outputs); err != nil {
+		return fmt.Errorf("could not parse terraform outputs: %w", err)
+	}
+
+	secret := &corev1.Secret{}
+	secret.Namespace = t.config.Namespace
+	secret.Name = t.config.OutputsSecretName
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, t.client, secret, func() error {
+		data := map[string][]byte{}
+		for name, output := range outputs {
+			data[name] = outputValueBytes(output.Value)
+		}
+		secret.Data = data
+		controllerutil.AddFinalizer(secret, TerraformerFinalizer)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not persist outputs secret: %w", err)
+	}
+
+	if t.config.OutputsConfigMapName != "" {
+		cm := &corev1.ConfigMap{}
+		cm.Namespace = t.config.Namespace
+		cm.Name = t.config.OutputsConfigMapName
+
+		if _, err := controllerutil.CreateOrUpdate(ctx, t.client, cm, func() error {
+			data := map[string]string{}
+			for name, output := range outputs {
+				if output.Sensitive {
+					continue
+				}
+				data[name] = string(outputValueBytes(output.Value))
+			}
+			cm.Data = data
+			controllerutil.AddFinalizer(cm, TerraformerFinalizer)
+			return nil
+		}); err != nil {
+			return fmt.Errorf("could not persist outputs configmap: %w", err)
+		}
+	}
+
+	t.logger.Info("successfully stored terraform outputs")
+	return nil
+}
+
+// outputValueBytes renders a terraform output value for storage: plain strings are unquoted so they can be
+// consumed directly (e.g. via secretKeyRef), while all other JSON types (numbers, lists, objects) are stored as
+// their raw JSON representation.
+func outputValueBytes(value json.RawMessage) []byte {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return []byte(s)
+	}
+	return []byte(value)
+}
+
+// removeOutputsFinalizers removes TerraformerFinalizer from the outputs Secret and, if configured, the outputs
+// ConfigMap, mirroring the configuration/state/variables cleanup on Destroy.
+func (t *Terraformer) removeOutputsFinalizers(ctx context.Context) error {
+	if t.config.OutputsSecretName != "" {
+		if secret, err := t.getOutputsSecret(ctx); err == nil {
+			if err := t.removeFinalizer(ctx, secret); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.config.OutputsConfigMapName != "" {
+		if cm, err := t.getOutputsConfigMap(ctx); err == nil {
+			if err := t.removeFinalizer(ctx, cm); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
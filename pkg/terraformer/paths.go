@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import "path/filepath"
+
+// PathSet bundles all filesystem paths the Terraformer reads from and writes to. All paths except JUnitReportPath
+// default to a location under BaseDir, which allows tests to redirect the whole tree into a temporary directory
+// via WithBaseDir.
+type PathSet struct {
+	// BaseDir is the working directory terraform is invoked in.
+	BaseDir string
+
+	// ConfigDir contains the unpacked terraform configuration (main.tf and friends).
+	ConfigDir string
+	// VarsFile is the location terraform variables are written to before invoking terraform.
+	VarsFile string
+	// StateFile is the location the terraform state is written to before invoking terraform, and read from
+	// afterwards in order to persist it back through the configured StateStore.
+	StateFile string
+
+	// TerminationMessagePath is read by Kubernetes as the pod's termination message. Terraformer writes the
+	// combined terraform output here so that it shows up in `kubectl describe pod` on failure.
+	TerminationMessagePath string
+
+	// JUnitReportPath is where Run(Validate) writes its JUnit XML report, if Config.JUnitReportPath is set. It has
+	// no default under BaseDir and is empty unless set.
+	JUnitReportPath string
+
+	// PlanFile is where Run(Plan) writes the binary plan file terraform produces, and where Run(Apply) writes it
+	// back to before applying it, if Config.UsePlan is set.
+	PlanFile string
+}
+
+// DefaultPaths returns the PathSet used when running as a Gardener extension pod.
+func DefaultPaths() *PathSet {
+	return (&PathSet{}).WithBaseDir("/tf")
+}
+
+// WithBaseDir returns a copy of the PathSet with all paths relocated under the given base directory. It is used
+// by tests to isolate each run in its own temporary directory.
+func (p *PathSet) WithBaseDir(baseDir string) *PathSet {
+	out := *p
+	out.BaseDir = baseDir
+	out.ConfigDir = filepath.Join(baseDir, "config")
+	out.VarsFile = filepath.Join(baseDir, "terraform.tfvars")
+	out.StateFile = filepath.Join(baseDir, "terraform.tfstate")
+	out.TerminationMessagePath = filepath.Join(baseDir, "termination-log")
+	out.PlanFile = filepath.Join(baseDir, "terraform.tfplan")
+	return &out
+}
@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controllerutil"
+)
+
+const (
+	planKey        = "plan"
+	planSummaryKey = "summary.json"
+	planConfigHash = "config-hash"
+)
+
+// configHash fingerprints the given configuration ConfigMap, so a persisted plan can be checked for staleness
+// (config drift) before it is applied.
+func configHash(cm *corev1.ConfigMap) string {
+	names := make([]string, 0, len(cm.Data))
+	for name := range cm.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		_, _ = h.Write([]byte(name))
+		_, _ = h.Write([]byte(cm.Data[name]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (t *Terraformer) getPlanConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := t.client.Get(ctx, types.NamespacedName{Namespace: t.config.Namespace, Name: t.config.PlanConfigMapName}, cm); err != nil {
+		return nil, fmt.Errorf("could not fetch plan configmap: %w", err)
+	}
+	return cm, nil
+}
+
+// persistPlan gzips and base64-encodes the plan file at t.paths.PlanFile and stores it, together with the
+// `terraform show -json` summary and a fingerprint of the configuration it was created from, in the plan
+// ConfigMap (creating it if necessary).
+func (t *Terraformer) persistPlan(ctx context.Context, configCM *corev1.ConfigMap, summary []byte) error {
+	planBytes, err := ioutil.ReadFile(t.paths.PlanFile)
+	if err != nil {
+		return fmt.Errorf("could not read plan file: %w", err)
+	}
+
+	var gzipped bytes.Buffer
+	gzWriter := gzip.NewWriter(&gzipped)
+	if _, err := gzWriter.Write(planBytes); err != nil {
+		return fmt.Errorf("could not gzip plan file: %w", err)
+	}
+	if err := gzWriter.Close(); err != nil {
+		return fmt.Errorf("could not gzip plan file: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{}
+	cm.Namespace = t.config.Namespace
+	cm.Name = t.config.PlanConfigMapName
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, t.client, cm, func() error {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[planKey] = base64.StdEncoding.EncodeToString(gzipped.Bytes())
+		cm.Data[planSummaryKey] = string(summary)
+		cm.Data[planConfigHash] = configHash(configCM)
+		controllerutil.AddFinalizer(cm, TerraformerFinalizer)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not persist plan: %w", err)
+	}
+
+	t.logger.Info("successfully stored terraform plan", "planConfigMap", t.config.PlanConfigMapName)
+	return nil
+}
+
+// loadPlan fetches the persisted plan, checks it was created from the current configuration (rejecting it as
+// stale otherwise), decodes it and writes it to t.paths.PlanFile so it can be passed to `terraform apply`.
+func (t *Terraformer) loadPlan(ctx context.Context, configCM *corev1.ConfigMap) error {
+	cm, err := t.getPlanConfigMap(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cm.Data[planConfigHash] != configHash(configCM) {
+		return fmt.Errorf("stored plan in configmap %q is stale: configuration has changed since it was created", t.config.PlanConfigMapName)
+	}
+
+	gzipped, err := base64.StdEncoding.DecodeString(cm.Data[planKey])
+	if err != nil {
+		return fmt.Errorf("could not decode plan: %w", err)
+	}
+
+	gzReader, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		return fmt.Errorf("could not decompress plan: %w", err)
+	}
+	defer gzReader.Close()
+
+	planBytes, err := ioutil.ReadAll(gzReader)
+	if err != nil {
+		return fmt.Errorf("could not decompress plan: %w", err)
+	}
+
+	if err := ioutil.WriteFile(t.paths.PlanFile, planBytes, 0644); err != nil {
+		return fmt.Errorf("could not write plan file: %w", err)
+	}
+
+	return nil
+}
+
+// removePlanFinalizer removes TerraformerFinalizer from the plan ConfigMap, if it exists, mirroring the
+// configuration/state/variables cleanup on Destroy.
+func (t *Terraformer) removePlanFinalizer(ctx context.Context) error {
+	if t.config.PlanConfigMapName == "" {
+		return nil
+	}
+
+	cm, err := t.getPlanConfigMap(ctx)
+	if err != nil {
+		return nil //nolint:nilerr // no plan was ever created, nothing to clean up
+	}
+	return t.removeFinalizer(ctx, cm)
+}
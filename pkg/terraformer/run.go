@@ -0,0 +1,332 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/gardener/terraformer/pkg/utils"
+)
+
+// supportedCommands are the commands that may be requested via Run. Init is always run as a prerequisite and
+// cannot be requested directly.
+var supportedCommands = map[Command]bool{
+	Plan:     true,
+	Apply:    true,
+	Destroy:  true,
+	Validate: true,
+}
+
+// Run fetches configuration, variables and state from Kubernetes, executes the requested terraform command
+// against them, and persists the resulting state back.
+func (t *Terraformer) Run(command Command) error {
+	if !supportedCommands[command] {
+		return fmt.Errorf("command %q is not supported", command)
+	}
+
+	ctx := context.Background()
+
+	configCM, err := t.getConfigurationConfigMap(ctx)
+	if err != nil {
+		return err
+	}
+
+	varsSecret, err := t.getVariablesSecret(ctx)
+	if err != nil {
+		return err
+	}
+
+	stateStore, err := t.newStateStore(varsSecret)
+	if err != nil {
+		return fmt.Errorf("could not create state store: %w", err)
+	}
+
+	state, err := stateStore.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if command == Destroy && isStateEmpty(state) {
+		t.logger.Info("no state found, skipping terraform destroy")
+		return t.removeFinalizers(ctx, stateStore)
+	}
+
+	if err := t.ensureFinalizers(ctx, configCM, varsSecret); err != nil {
+		return err
+	}
+	if err := stateStore.EnsureFinalizer(ctx); err != nil {
+		return err
+	}
+
+	if err := t.prepareWorkingDirectory(configCM, varsSecret, state); err != nil {
+		return err
+	}
+
+	if command == Apply && t.config.UsePlan {
+		if err := t.loadPlan(ctx, configCM); err != nil {
+			return fmt.Errorf("could not use stored plan: %w", err)
+		}
+	}
+
+	if _, _, err := t.runCommand(ctx, "init"); err != nil {
+		return t.handleFailure(ctx, stateStore, err)
+	}
+
+	if version := stateTerraformVersion(state); strings.HasPrefix(version, "0.12.") {
+		if _, _, err := t.runCommand(ctx, "state", "replace-provider", "-auto-approve", "registry.terraform.io/-/null", "registry.terraform.io/hashicorp/null"); err != nil {
+			return t.handleFailure(ctx, stateStore, err)
+		}
+	}
+
+	var (
+		diagnostics []*structuredDiagnostic
+		planSummary []byte
+		outputsRaw  []byte
+	)
+	validateStart := t.clock.Now()
+
+	switch command {
+	case Plan:
+		if _, _, err = t.runCommand(ctx, "plan", "-out="+t.paths.PlanFile); err == nil {
+			planSummary, _, err = t.runCommand(ctx, "show", "-json", t.paths.PlanFile)
+		}
+	case Apply:
+		if t.config.UsePlan {
+			_, _, err = t.runCommand(ctx, "apply", t.paths.PlanFile)
+		} else {
+			_, _, err = t.runCommand(ctx, "apply", "-auto-approve")
+		}
+		if err == nil && t.config.OutputsSecretName != "" {
+			outputsRaw, _, err = t.runCommand(ctx, "output", "-json")
+		}
+	case Destroy:
+		_, _, err = t.runCommand(ctx, "destroy", "-auto-approve")
+	case Validate:
+		var validateDiags, planDiags []*structuredDiagnostic
+		if validateDiags, _, err = t.runCommand(ctx, "validate"); err == nil {
+			planDiags, _, err = t.runCommand(ctx, "plan")
+		}
+		diagnostics = append(validateDiags, planDiags...)
+	}
+
+	if t.paths.JUnitReportPath != "" && command == Validate {
+		if reportErr := t.writeJUnitReport(stateTerraformVersion(state), validateStart, t.clock.Since(validateStart), diagnostics, err); reportErr != nil {
+			t.logger.Error(reportErr, "could not write JUnit report")
+		}
+	}
+
+	if err != nil {
+		return t.handleFailure(ctx, stateStore, err)
+	}
+
+	t.logger.Info("terraform process finished successfully")
+
+	if err := ioutil.WriteFile(t.paths.TerminationMessagePath, nil, 0644); err != nil {
+		return fmt.Errorf("could not clear termination message file: %w", err)
+	}
+
+	if command == Plan {
+		return t.persistPlan(ctx, configCM, planSummary)
+	}
+
+	if command != Validate {
+		newState, err := ioutil.ReadFile(t.paths.StateFile)
+		if err != nil {
+			return fmt.Errorf("could not read terraform state after successful run: %w", err)
+		}
+		if err := stateStore.Store(ctx, newState); err != nil {
+			return err
+		}
+	}
+
+	if command == Apply && t.config.OutputsSecretName != "" {
+		if err := t.persistOutputs(ctx, outputsRaw); err != nil {
+			return err
+		}
+	}
+
+	if command == Destroy {
+		if err := t.removePlanFinalizer(ctx); err != nil {
+			return err
+		}
+		if err := t.removeOutputsFinalizers(ctx); err != nil {
+			return err
+		}
+		return t.removeFinalizers(ctx, stateStore)
+	}
+
+	return nil
+}
+
+// handleFailure is invoked whenever a terraform invocation failed. It triggers one last state update (so that
+// partial progress is not lost) before returning the original error to the caller.
+func (t *Terraformer) handleFailure(ctx context.Context, stateStore StateStore, runErr error) error {
+	t.logger.Error(runErr, "terraform process finished with error")
+	t.logger.Info("triggering final state update before exiting")
+
+	flushed := false
+	if state, readErr := ioutil.ReadFile(t.paths.StateFile); readErr == nil {
+		if err := stateStore.Store(ctx, state); err != nil {
+			t.logger.Error(err, "could not persist terraform state after failed run")
+		} else {
+			flushed = true
+		}
+	}
+	t.appendTerminationMarker(flushed)
+
+	if t.config.OutputsSecretName != "" {
+		// runCommand overwrites the termination message on failure, so preserve the one just written above for
+		// runErr in case fetching outputs fails too - it must not be clobbered by a less informative message.
+		terminationMessage, _ := ioutil.ReadFile(t.paths.TerminationMessagePath)
+
+		if outputsRaw, _, outputErr := t.runCommand(ctx, "output", "-json"); outputErr == nil {
+			if err := t.persistOutputs(ctx, outputsRaw); err != nil {
+				t.logger.Error(err, "could not persist terraform outputs after failed run")
+			}
+		} else {
+			_ = ioutil.WriteFile(t.paths.TerminationMessagePath, terminationMessage, 0644)
+		}
+	}
+
+	return runErr
+}
+
+// appendTerminationMarker appends a marker line to the termination message file recording whether the final
+// state update attempted by handleFailure actually succeeded, so operators reading the termination message
+// can tell a clean state flush apart from one that failed mid-operation.
+func (t *Terraformer) appendTerminationMarker(flushed bool) {
+	marker := terminationMarkerNotFlushed
+	if flushed {
+		marker = terminationMarkerFlushed
+	}
+
+	terminationMessage, _ := ioutil.ReadFile(t.paths.TerminationMessagePath)
+	terminationMessage = append(terminationMessage, []byte(marker+"\n")...)
+	if err := ioutil.WriteFile(t.paths.TerminationMessagePath, terminationMessage, 0644); err != nil {
+		t.logger.Error(err, "could not update termination message file with flush marker")
+	}
+}
+
+// runCommand executes the given terraform subcommand and args, streaming its combined output to Terraformer's
+// logger/Stderr and, on failure, to the termination message file. If Config.StructuredLogs is set and the
+// subcommand supports it, `-json` is added and the resulting NDJSON stream is parsed and re-emitted as
+// structured log fields instead of being copied through verbatim.
+func (t *Terraformer) runCommand(ctx context.Context, args ...string) ([]*structuredDiagnostic, []byte, error) {
+	// JUnit reporting needs terraform's diagnostics for validate/plan even if structured logging is otherwise
+	// disabled, so it forces -json for just those two subcommands.
+	needsDiagnostics := t.paths.JUnitReportPath != "" && (args[0] == "validate" || args[0] == "plan")
+	structured := (t.config.StructuredLogs || needsDiagnostics) && commandSupportsJSON(args)
+	if structured {
+		args = append(args, "-json")
+	}
+
+	cmd := exec.CommandContext(ctx, TerraformBinary, args...)
+	cmd.Dir = t.paths.BaseDir
+	cmd.Env = append(os.Environ(), "TF_IN_AUTOMATION=true")
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not attach to terraform stdout: %w", err)
+	}
+	cmd.Stderr = cmd.Stdout
+
+	t.logger.Info("args: " + strings.Join(args, " "))
+
+	var (
+		transcript  strings.Builder
+		output      strings.Builder
+		diagnostics []*structuredDiagnostic
+	)
+	fmt.Fprintf(&transcript, "args: %s\n", strings.Join(args, " "))
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("could not start terraform: %w", err)
+	}
+
+	relay := newSignalRelay(cmd, t.config.ExitTimeout, t.clock, t.logger)
+	stopRelaying := relay.start()
+	defer stopRelaying()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if structured {
+			if msg, ok := parseStructuredLogLine(line); ok {
+				if diag := t.logStructuredMessage(msg); diag != nil {
+					diagnostics = append(diagnostics, diag)
+				}
+				continue
+			}
+		}
+
+		transcript.WriteString(line)
+		transcript.WriteString("\n")
+		output.WriteString(line)
+		output.WriteString("\n")
+		fmt.Fprintln(Stderr, line)
+	}
+
+	if rendered := renderDiagnostics(diagnostics); rendered != "" {
+		transcript.WriteString(rendered)
+		fmt.Fprint(Stderr, rendered)
+	}
+
+	waitErr := cmd.Wait()
+
+	if relay.forcedKilled() {
+		transcript.WriteString(terminationMarkerForcedKill + "\n")
+		_ = ioutil.WriteFile(t.paths.TerminationMessagePath, []byte(transcript.String()), 0644)
+		return diagnostics, nil, utils.NewWithExitCode(fmt.Errorf("terraform command was force-killed after exceeding ExitTimeout of %s", t.config.ExitTimeout), forcedKillExitCode)
+	}
+
+	if waitErr != nil {
+		_ = ioutil.WriteFile(t.paths.TerminationMessagePath, []byte(transcript.String()), 0644)
+
+		exitCode := -1
+		if exitErr, ok := waitErr.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+		return diagnostics, nil, utils.NewWithExitCode(fmt.Errorf("terraform command failed: %w", waitErr), exitCode)
+	}
+
+	return diagnostics, []byte(output.String()), nil
+}
+
+// prepareWorkingDirectory writes the configuration, variables and state to disk so the terraform binary can
+// pick them up from Terraformer's BaseDir.
+func (t *Terraformer) prepareWorkingDirectory(configCM *corev1.ConfigMap, varsSecret *corev1.Secret, state []byte) error {
+	if err := os.MkdirAll(t.paths.ConfigDir, 0755); err != nil {
+		return fmt.Errorf("could not create config dir: %w", err)
+	}
+
+	for name, content := range configCM.Data {
+		if err := ioutil.WriteFile(t.paths.ConfigDir+"/"+name, []byte(content), 0644); err != nil {
+			return fmt.Errorf("could not write configuration file %q: %w", name, err)
+		}
+	}
+
+	var vars strings.Builder
+	for name, value := range varsSecret.Data {
+		fmt.Fprintf(&vars, "%s = %q\n", name, string(value))
+	}
+	if err := ioutil.WriteFile(t.paths.VarsFile, []byte(vars.String()), 0600); err != nil {
+		return fmt.Errorf("could not write variables file: %w", err)
+	}
+
+	if err := ioutil.WriteFile(t.paths.StateFile, state, 0644); err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+
+	return nil
+}
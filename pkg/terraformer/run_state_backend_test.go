@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	testutils "github.com/gardener/terraformer/test/utils"
+)
+
+// This suite parameterizes the "triggering final state update before exiting" failure path over every
+// Config.StateBackend, using a fake blobClient for S3/GCS/Azure so no real cloud credentials or network access
+// are needed. The "state flushed before exiting" guarantee handleFailure gives operators must hold no matter
+// which backend Run was configured with, not just the ConfigMap default terraformer_test.go otherwise covers.
+var _ = Describe("Run failure path across state backends", func() {
+	const namespace, configurationName, stateName, variablesName = "test-failure", "configuration", "state", "variables"
+
+	for _, backend := range []struct {
+		name      string
+		configure func(config *Config)
+	}{
+		{
+			name:      string(StateBackendConfigMap),
+			configure: func(config *Config) { config.StateBackend = StateBackendConfigMap },
+		},
+		{
+			name: string(StateBackendS3),
+			configure: func(config *Config) {
+				config.StateBackend = StateBackendS3
+				config.StateBackendBucketName = "bucket"
+			},
+		},
+		{
+			name: string(StateBackendGCS),
+			configure: func(config *Config) {
+				config.StateBackend = StateBackendGCS
+				config.StateBackendBucketName = "bucket"
+			},
+		},
+		{
+			name: string(StateBackendAzureBlob),
+			configure: func(config *Config) {
+				config.StateBackend = StateBackendAzureBlob
+				config.StateBackendBucketName = "container"
+				config.StateBackendAzureStorageAccount = "account"
+			},
+		},
+	} {
+		backend := backend
+
+		Describe(backend.name, func() {
+			var (
+				fakeTerraform testutils.FakeTerraform
+				tf            *Terraformer
+				baseDir       string
+				logBuffer     *gbytes.Buffer
+
+				originalBinary   string
+				resetBlobClients func()
+			)
+
+			BeforeEach(func() {
+				var err error
+				baseDir, err = ioutil.TempDir("", "tf-backend-test-*")
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeClient := fake.NewClientBuilder().Build()
+
+				for _, obj := range []client.Object{
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: configurationName}, Data: map[string]string{"main.tf": "# test configuration\n"}},
+					&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: stateName}},
+					&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: variablesName}},
+				} {
+					Expect(fakeClient.Create(context.Background(), obj)).To(Succeed())
+				}
+
+				resetBlobClients = swapBlobClientsWithFakes()
+
+				config := &Config{
+					Namespace:                  namespace,
+					ConfigurationConfigMapName: configurationName,
+					StateConfigMapName:         stateName,
+					VariablesSecretName:        variablesName,
+				}
+				backend.configure(config)
+
+				logBuffer = gbytes.NewBuffer()
+				tf = &Terraformer{
+					config: config,
+					logger: zap.New(zap.UseDevMode(true), zap.WriteTo(io.MultiWriter(GinkgoWriter, logBuffer))),
+					paths:  DefaultPaths().WithBaseDir(baseDir),
+					clock:  clock.RealClock{},
+					client: fakeClient,
+				}
+
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCodeForCommands("init", "0", "apply", "42"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+				originalBinary = TerraformBinary
+				TerraformBinary = fakeTerraform.Path
+			})
+
+			AfterEach(func() {
+				Expect(os.RemoveAll(baseDir)).To(Succeed())
+				resetBlobClients()
+				TerraformBinary = originalBinary
+			})
+
+			It("should flush state through the configured backend before returning the error", func() {
+				err := tf.Run(Apply)
+				Expect(err).To(MatchError(ContainSubstring("terraform command failed")))
+
+				Eventually(logBuffer).Should(gbytes.Say("terraform process finished with error"))
+				Eventually(logBuffer).Should(gbytes.Say("triggering final state update before exiting"))
+				Eventually(logBuffer).Should(gbytes.Say("successfully stored terraform state"))
+
+				Expect(tf.paths.TerminationMessagePath).To(testutils.BeFileWithContents(ContainSubstring(terminationMarkerFlushed)))
+			})
+		})
+	}
+})
+
+// swapBlobClientsWithFakes replaces the S3/GCS/Azure blob client builders with fakes for the duration of a test,
+// returning a function that restores the real ones.
+func swapBlobClientsWithFakes() func() {
+	originalS3, originalGCS, originalAzure := newS3Client, newGCSClient, newAzureBlobClient
+
+	fakeBuilder := func(*Config, *corev1.Secret) (blobClient, error) { return newFakeBlobClient(), nil }
+	newS3Client, newGCSClient, newAzureBlobClient = fakeBuilder, fakeBuilder, fakeBuilder
+
+	return func() {
+		newS3Client, newGCSClient, newAzureBlobClient = originalS3, originalGCS, originalAzure
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// DefaultExitTimeout is used when Config.ExitTimeout is not set.
+const DefaultExitTimeout = 3 * time.Minute
+
+// forcedKillExitCode is the exit code reported when terraform had to be escalated to SIGKILL, matching the
+// conventional 128+SIGKILL(9) shell exit status.
+const forcedKillExitCode = 137
+
+// Markers appended to the termination message file so that operators reading a pod's
+// terminationMessagePolicy=FallbackToLogsOnError output can tell at a glance whether state was flushed cleanly
+// or the run was killed mid-operation.
+const (
+	terminationMarkerFlushed    = "TERRAFORMER: state flushed before exiting"
+	terminationMarkerNotFlushed = "TERRAFORMER: state could not be flushed before exiting, state may be inconsistent"
+	terminationMarkerForcedKill = "TERRAFORMER: forced termination (SIGKILL) after ExitTimeout, state may be inconsistent"
+)
+
+// signalRelay forwards SIGINT/SIGTERM received by this process to a terraform child process as SIGINT, so that
+// terraform gets a chance to gracefully cancel the in-flight operation and flush its state. If the child does
+// not exit within exitTimeout of the first signal, it is escalated to SIGKILL. It uses a clock.Clock (rather
+// than the time package directly) so tests can control the escalation deadline with a fake clock.
+type signalRelay struct {
+	cmd         *exec.Cmd
+	exitTimeout time.Duration
+	clock       clock.Clock
+	logger      logr.Logger
+
+	forcedKill int32 // set atomically
+}
+
+func newSignalRelay(cmd *exec.Cmd, exitTimeout time.Duration, clock clock.Clock, logger logr.Logger) *signalRelay {
+	return &signalRelay{cmd: cmd, exitTimeout: exitTimeout, clock: clock, logger: logger}
+}
+
+// start begins relaying signals and returns a stop function that must be called once the child has finished
+// (successfully or not), to stop relaying and release resources.
+func (r *signalRelay) start() (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	SignalNotify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCh:
+		case <-done:
+			return
+		}
+
+		if r.cmd.Process != nil {
+			_ = r.cmd.Process.Signal(syscall.SIGINT)
+		}
+
+		timer := r.clock.NewTimer(r.exitTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C():
+			r.logger.Info("terraform did not exit within ExitTimeout, escalating to SIGKILL", "exitTimeout", r.exitTimeout)
+			atomic.StoreInt32(&r.forcedKill, 1)
+			if r.cmd.Process != nil {
+				_ = r.cmd.Process.Kill()
+			}
+		case <-done:
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// forcedKilled reports whether the child had to be escalated to SIGKILL. It must only be called after the child
+// has exited (i.e. after cmd.Wait returned).
+func (r *signalRelay) forcedKilled() bool {
+	return atomic.LoadInt32(&r.forcedKill) == 1
+}
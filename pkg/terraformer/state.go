@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controllerutil"
+)
+
+// stateKey is the key under which the terraform state is stored by the ConfigMap-backed StateStore.
+const stateKey = "terraform.tfstate"
+
+// isStateEmpty returns true if the given state does not carry any terraform state, i.e. a Destroy can be
+// skipped because there is nothing left to destroy.
+func isStateEmpty(state []byte) bool {
+	return len(state) == 0
+}
+
+// stateTerraformVersion extracts the `terraform_version` field from the given state, if any.
+func stateTerraformVersion(state []byte) string {
+	if len(state) == 0 {
+		return ""
+	}
+
+	var parsed struct {
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(state, &parsed); err != nil {
+		return ""
+	}
+	return parsed.TerraformVersion
+}
+
+func (t *Terraformer) getConfigurationConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := t.client.Get(ctx, types.NamespacedName{Namespace: t.config.Namespace, Name: t.config.ConfigurationConfigMapName}, cm); err != nil {
+		return nil, fmt.Errorf("could not fetch configuration configmap: %w", err)
+	}
+	return cm, nil
+}
+
+func (t *Terraformer) getVariablesSecret(ctx context.Context) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := t.client.Get(ctx, types.NamespacedName{Namespace: t.config.Namespace, Name: t.config.VariablesSecretName}, secret); err != nil {
+		return nil, fmt.Errorf("could not fetch variables secret: %w", err)
+	}
+	return secret, nil
+}
+
+// configMapStateStore is the default StateStore: it reads and writes the full terraform state inline in a
+// ConfigMap. This is subject to Kubernetes' ~1MiB object size limit, which alternative StateStore
+// implementations (see state_s3.go, state_gcs.go, state_azureblob.go) exist to work around.
+type configMapStateStore struct {
+	client    client.Client
+	logger    logr.Logger
+	namespace string
+	name      string
+}
+
+func newConfigMapStateStore(c client.Client, logger logr.Logger, namespace, name string) *configMapStateStore {
+	return &configMapStateStore{client: c, logger: logger, namespace: namespace, name: name}
+}
+
+func (s *configMapStateStore) get(ctx context.Context) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: s.namespace, Name: s.name}, cm); err != nil {
+		return nil, fmt.Errorf("could not fetch state configmap: %w", err)
+	}
+	return cm, nil
+}
+
+// Fetch returns the state stored under stateKey in the state ConfigMap, or nil if the ConfigMap carries no
+// state yet. The ConfigMap itself is expected to already exist (it is part of the CRD contract), so a missing
+// ConfigMap is still surfaced as an error.
+func (s *configMapStateStore) Fetch(ctx context.Context) ([]byte, error) {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cm.Data[stateKey] == "" {
+		return nil, nil
+	}
+	return []byte(cm.Data[stateKey]), nil
+}
+
+// Store writes the given state back to the state ConfigMap, creating it if necessary.
+func (s *configMapStateStore) Store(ctx context.Context, state []byte) error {
+	cm := &corev1.ConfigMap{}
+	cm.Namespace = s.namespace
+	cm.Name = s.name
+
+	if _, err := controllerutil.CreateOrUpdate(ctx, s.client, cm, func() error {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[stateKey] = string(state)
+		controllerutil.AddFinalizer(cm, TerraformerFinalizer)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("could not persist terraform state: %w", err)
+	}
+
+	s.logger.Info("successfully stored terraform state")
+	return nil
+}
+
+// EnsureFinalizer adds TerraformerFinalizer to the state ConfigMap so it cannot be deleted concurrently.
+func (s *configMapStateStore) EnsureFinalizer(ctx context.Context) error {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return err
+	}
+	if controllerutil.ContainsFinalizer(cm, TerraformerFinalizer) {
+		return nil
+	}
+	controllerutil.AddFinalizer(cm, TerraformerFinalizer)
+	if err := s.client.Update(ctx, cm); err != nil {
+		return fmt.Errorf("could not add finalizer to state configmap %q: %w", s.name, err)
+	}
+	return nil
+}
+
+// RemoveFinalizer removes TerraformerFinalizer from the state ConfigMap, if it exists.
+func (s *configMapStateStore) RemoveFinalizer(ctx context.Context) error {
+	cm, err := s.get(ctx)
+	if err != nil {
+		return nil //nolint:nilerr // the configmap is already gone, nothing to clean up
+	}
+	if !controllerutil.ContainsFinalizer(cm, TerraformerFinalizer) {
+		return nil
+	}
+	controllerutil.RemoveFinalizer(cm, TerraformerFinalizer)
+	if err := s.client.Update(ctx, cm); err != nil {
+		return fmt.Errorf("could not remove finalizer from state configmap %q: %w", s.name, err)
+	}
+	return nil
+}
+
+// ensureFinalizers adds TerraformerFinalizer to the configuration and variables objects so neither can be
+// deleted while terraformer is working with them. The state object's finalizer is owned by the Run's
+// StateStore instead, since its backing object(s) depend on Config.StateBackend.
+func (t *Terraformer) ensureFinalizers(ctx context.Context, configCM *corev1.ConfigMap, varsSecret *corev1.Secret) error {
+	for _, obj := range []client.Object{configCM, varsSecret} {
+		if !controllerutil.ContainsFinalizer(obj, TerraformerFinalizer) {
+			controllerutil.AddFinalizer(obj, TerraformerFinalizer)
+			if err := t.client.Update(ctx, obj); err != nil {
+				return fmt.Errorf("could not add finalizer to %T %q: %w", obj, obj.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// removeFinalizers removes TerraformerFinalizer from the configuration, variables and state objects once
+// terraformer is done with them (i.e. after a successful Destroy).
+func (t *Terraformer) removeFinalizers(ctx context.Context, stateStore StateStore) error {
+	configCM, err := t.getConfigurationConfigMap(ctx)
+	if err == nil {
+		if err := t.removeFinalizer(ctx, configCM); err != nil {
+			return err
+		}
+	}
+
+	varsSecret, err := t.getVariablesSecret(ctx)
+	if err == nil {
+		if err := t.removeFinalizer(ctx, varsSecret); err != nil {
+			return err
+		}
+	}
+
+	return stateStore.RemoveFinalizer(ctx)
+}
+
+func (t *Terraformer) removeFinalizer(ctx context.Context, obj client.Object) error {
+	if !controllerutil.ContainsFinalizer(obj, TerraformerFinalizer) {
+		return nil
+	}
+	controllerutil.RemoveFinalizer(obj, TerraformerFinalizer)
+	if err := t.client.Update(ctx, obj); err != nil {
+		return fmt.Errorf("could not remove finalizer from %T %q: %w", obj, obj.GetName(), err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// azureBlobClient adapts an *azblob.ServiceClient to the blobClient interface.
+type azureBlobClient struct {
+	client    *azblob.ServiceClient
+	container string
+}
+
+func (c *azureBlobClient) GetObject(ctx context.Context, key string) ([]byte, bool, error) {
+	blobClient := c.client.NewContainerClient(c.container).NewBlobClient(key)
+
+	resp, err := blobClient.Download(ctx, nil)
+	if err != nil {
+		var respErr *azcore.ResponseError
+		if azcore.IsResponseError(err, &respErr) && respErr.StatusCode == 404 {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	body := resp.Body(nil)
+	defer body.Close()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (c *azureBlobClient) PutObject(ctx context.Context, key string, data []byte) error {
+	blobClient := c.client.NewContainerClient(c.container).NewBlockBlobClient(key)
+	_, err := blobClient.UploadBuffer(ctx, data, nil)
+	return err
+}
+
+// newAzureBlobClient builds the blobClient used by newAzureBlobStateStore from the CLIENT_ID/CLIENT_SECRET/
+// TENANT_ID credentials in varsSecret. It is a variable, like TerraformBinary, so tests can substitute a fake
+// blobClient instead of making real Azure calls.
+var newAzureBlobClient = func(config *Config, varsSecret *corev1.Secret) (blobClient, error) {
+	clientID, clientSecret := string(varsSecret.Data["CLIENT_ID"]), string(varsSecret.Data["CLIENT_SECRET"])
+	tenantID := string(varsSecret.Data["TENANT_ID"])
+	if clientID == "" || clientSecret == "" || tenantID == "" {
+		return nil, fmt.Errorf("variables secret %q is missing CLIENT_ID/CLIENT_SECRET/TENANT_ID for state backend %q", config.VariablesSecretName, StateBackendAzureBlob)
+	}
+
+	cred, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not build azure credential: %w", err)
+	}
+
+	serviceClient, err := azblob.NewServiceClient(fmt.Sprintf("https://%s.blob.core.windows.net/", config.StateBackendAzureStorageAccount), cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not build azure blob client: %w", err)
+	}
+
+	return &azureBlobClient{client: serviceClient, container: config.StateBackendBucketName}, nil
+}
+
+// newAzureBlobStateStore builds a StateStore that persists terraform state as a blob in
+// Config.StateBackendBucketName (used as the container name, inside Config.StateBackendAzureStorageAccount),
+// using the CLIENT_ID/CLIENT_SECRET/TENANT_ID credentials from varsSecret - the same Secret that already holds
+// the terraform provider's Azure credentials.
+func newAzureBlobStateStore(c client.Client, logger logr.Logger, config *Config, varsSecret *corev1.Secret) (StateStore, error) {
+	if config.StateBackendBucketName == "" {
+		return nil, fmt.Errorf("StateBackendBucketName must be set for state backend %q", StateBackendAzureBlob)
+	}
+	if config.StateBackendAzureStorageAccount == "" {
+		return nil, fmt.Errorf("StateBackendAzureStorageAccount must be set for state backend %q", StateBackendAzureBlob)
+	}
+
+	blob, err := newAzureBlobClient(config, varsSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlobStateStore(c, logger, config.Namespace, config.StateConfigMapName, blob), nil
+}
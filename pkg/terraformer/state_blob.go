@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// blobClient abstracts the object-storage operations needed by a blob-backed StateStore, so the S3, GCS and
+// Azure Blob implementations (state_s3.go, state_gcs.go, state_azureblob.go) can share the pointer-ConfigMap
+// bookkeeping in blobStateStore instead of duplicating it.
+type blobClient interface {
+	// GetObject returns the object's content. found is false if the object does not exist yet.
+	GetObject(ctx context.Context, key string) (data []byte, found bool, err error)
+	// PutObject creates or overwrites the object.
+	PutObject(ctx context.Context, key string, data []byte) error
+}
+
+// blobStateStore persists terraform state as a single object in a bucket/container, keyed by namespace/name.
+// Config.StateConfigMapName is kept around as a (data-less) pointer object purely so it can still carry
+// TerraformerFinalizer - other controllers rely on that ConfigMap existing as part of the CRD contract, and
+// changing that would defeat the point of adding this as an alternative backend rather than a breaking change.
+type blobStateStore struct {
+	pointer   *configMapStateStore
+	blob      blobClient
+	objectKey string
+	logger    logr.Logger
+}
+
+func newBlobStateStore(c client.Client, logger logr.Logger, namespace, stateConfigMapName string, blob blobClient) *blobStateStore {
+	return &blobStateStore{
+		pointer:   newConfigMapStateStore(c, logger, namespace, stateConfigMapName),
+		blob:      blob,
+		objectKey: namespace + "/" + stateConfigMapName + ".tfstate",
+		logger:    logger,
+	}
+}
+
+// Fetch requires the pointer ConfigMap to exist (it is part of the CRD contract, same as the ConfigMap-backed
+// store), but treats a missing state object as "no state yet" rather than an error.
+func (s *blobStateStore) Fetch(ctx context.Context) ([]byte, error) {
+	if _, err := s.pointer.get(ctx); err != nil {
+		return nil, err
+	}
+
+	data, found, err := s.blob.GetObject(ctx, s.objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch terraform state object %q: %w", s.objectKey, err)
+	}
+	if !found {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func (s *blobStateStore) Store(ctx context.Context, state []byte) error {
+	if err := s.blob.PutObject(ctx, s.objectKey, state); err != nil {
+		return fmt.Errorf("could not persist terraform state object %q: %w", s.objectKey, err)
+	}
+
+	s.logger.Info("successfully stored terraform state")
+	return nil
+}
+
+func (s *blobStateStore) EnsureFinalizer(ctx context.Context) error {
+	return s.pointer.EnsureFinalizer(ctx)
+}
+
+func (s *blobStateStore) RemoveFinalizer(ctx context.Context) error {
+	return s.pointer.RemoveFinalizer(ctx)
+}
@@ -0,0 +1,83 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"google.golang.org/api/option"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// gcsClient adapts a *storage.Client to the blobClient interface.
+type gcsClient struct {
+	client *storage.Client
+	bucket string
+}
+
+func (c *gcsClient) GetObject(ctx context.Context, key string) ([]byte, bool, error) {
+	r, err := c.client.Bucket(c.bucket).Object(key).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (c *gcsClient) PutObject(ctx context.Context, key string, data []byte) error {
+	w := c.client.Bucket(c.bucket).Object(key).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// newGCSClient builds the blobClient used by newGCSStateStore from the service account key stored under the
+// SERVICEACCOUNT.JSON key of varsSecret. It is a variable, like TerraformBinary, so tests can substitute a fake
+// blobClient instead of making real GCS calls.
+var newGCSClient = func(config *Config, varsSecret *corev1.Secret) (blobClient, error) {
+	serviceAccountJSON := varsSecret.Data["SERVICEACCOUNT.JSON"]
+	if len(serviceAccountJSON) == 0 {
+		return nil, fmt.Errorf("variables secret %q is missing SERVICEACCOUNT.JSON for state backend %q", config.VariablesSecretName, StateBackendGCS)
+	}
+
+	gcsClientImpl, err := storage.NewClient(context.Background(), option.WithCredentialsJSON(serviceAccountJSON))
+	if err != nil {
+		return nil, fmt.Errorf("could not build gcs client: %w", err)
+	}
+
+	return &gcsClient{client: gcsClientImpl, bucket: config.StateBackendBucketName}, nil
+}
+
+// newGCSStateStore builds a StateStore that persists terraform state as an object in
+// Config.StateBackendBucketName, using the service account key stored under the SERVICEACCOUNT.JSON key of
+// varsSecret - the same Secret that already holds the terraform provider's GCP credentials.
+func newGCSStateStore(c client.Client, logger logr.Logger, config *Config, varsSecret *corev1.Secret) (StateStore, error) {
+	if config.StateBackendBucketName == "" {
+		return nil, fmt.Errorf("StateBackendBucketName must be set for state backend %q", StateBackendGCS)
+	}
+
+	blob, err := newGCSClient(config, varsSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlobStateStore(c, logger, config.Namespace, config.StateConfigMapName, blob), nil
+}
@@ -0,0 +1,90 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// s3Client adapts an *s3.Client to the blobClient interface.
+type s3Client struct {
+	client *s3.Client
+	bucket string
+}
+
+func (c *s3Client) GetObject(ctx context.Context, key string) ([]byte, bool, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(c.bucket), Key: aws.String(key)})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (c *s3Client) PutObject(ctx context.Context, key string, data []byte) error {
+	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// newS3Client builds the blobClient used by newS3StateStore from the ACCESS_KEY_ID/SECRET_ACCESS_KEY credentials
+// in varsSecret. It is a variable, like TerraformBinary, so tests can substitute a fake blobClient instead of
+// making real AWS calls.
+var newS3Client = func(config *Config, varsSecret *corev1.Secret) (blobClient, error) {
+	accessKeyID, secretAccessKey := string(varsSecret.Data["ACCESS_KEY_ID"]), string(varsSecret.Data["SECRET_ACCESS_KEY"])
+	if accessKeyID == "" || secretAccessKey == "" {
+		return nil, fmt.Errorf("variables secret %q is missing ACCESS_KEY_ID/SECRET_ACCESS_KEY for state backend %q", config.VariablesSecretName, StateBackendS3)
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not build aws config: %w", err)
+	}
+
+	return &s3Client{client: s3.NewFromConfig(awsCfg), bucket: config.StateBackendBucketName}, nil
+}
+
+// newS3StateStore builds a StateStore that persists terraform state as an object in Config.StateBackendBucketName,
+// using the ACCESS_KEY_ID/SECRET_ACCESS_KEY credentials from varsSecret - the same Secret that already holds the
+// terraform provider's AWS credentials.
+func newS3StateStore(c client.Client, logger logr.Logger, config *Config, varsSecret *corev1.Secret) (StateStore, error) {
+	if config.StateBackendBucketName == "" {
+		return nil, fmt.Errorf("StateBackendBucketName must be set for state backend %q", StateBackendS3)
+	}
+
+	blob, err := newS3Client(config, varsSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBlobStateStore(c, logger, config.Namespace, config.StateConfigMapName, blob), nil
+}
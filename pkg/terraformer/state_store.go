@@ -0,0 +1,67 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// StateStore abstracts where terraform state is read from and persisted to, so Run does not need to care
+// whether the state lives inline in a ConfigMap or in an object storage bucket. Implementations must keep the
+// "state is empty"/"state key is not available" semantics of the ConfigMap-backed default identical: Fetch
+// returns a nil/empty slice, not an error, when no state has been stored yet.
+type StateStore interface {
+	// Fetch returns the currently persisted state, or nil if none has been stored yet.
+	Fetch(ctx context.Context) ([]byte, error)
+	// Store persists the given state, creating any backing object(s) if necessary.
+	Store(ctx context.Context, state []byte) error
+	// EnsureFinalizer adds TerraformerFinalizer to the backing object(s) so they cannot be deleted concurrently.
+	EnsureFinalizer(ctx context.Context) error
+	// RemoveFinalizer removes TerraformerFinalizer from the backing object(s) once terraformer is done with them.
+	RemoveFinalizer(ctx context.Context) error
+}
+
+// StateBackend selects the StateStore implementation Run uses to read and persist terraform state.
+type StateBackend string
+
+const (
+	// StateBackendConfigMap stores the full state inline in Config.StateConfigMapName. This is the default, and
+	// is subject to Kubernetes' ~1MiB object size limit.
+	StateBackendConfigMap StateBackend = "configmap"
+	// StateBackendS3 stores the state as an object in Config.StateBackendBucketName, keeping only a pointer and
+	// the finalizer in Config.StateConfigMapName so the CRD contract does not change. Credentials are read from
+	// the ACCESS_KEY_ID/SECRET_ACCESS_KEY keys of the VariablesSecret.
+	StateBackendS3 StateBackend = "s3"
+	// StateBackendGCS stores the state as an object in Config.StateBackendBucketName, keeping only a pointer and
+	// the finalizer in Config.StateConfigMapName. Credentials are read from the SERVICEACCOUNT.JSON key of the
+	// VariablesSecret.
+	StateBackendGCS StateBackend = "gcs"
+	// StateBackendAzureBlob stores the state as a blob in the Config.StateBackendBucketName container of
+	// Config.StateBackendAzureStorageAccount, keeping only a pointer and the finalizer in
+	// Config.StateConfigMapName. Credentials are read from the CLIENT_ID/CLIENT_SECRET/TENANT_ID keys of the
+	// VariablesSecret.
+	StateBackendAzureBlob StateBackend = "azureblob"
+)
+
+// newStateStore builds the StateStore selected by config.StateBackend (defaulting to StateBackendConfigMap).
+// Cloud-backed stores derive their client from varsSecret, the same Secret used for terraform provider
+// credentials, so no separate credentials object is needed.
+func (t *Terraformer) newStateStore(varsSecret *corev1.Secret) (StateStore, error) {
+	switch t.config.StateBackend {
+	case "", StateBackendConfigMap:
+		return newConfigMapStateStore(t.client, t.logger, t.config.Namespace, t.config.StateConfigMapName), nil
+	case StateBackendS3:
+		return newS3StateStore(t.client, t.logger, t.config, varsSecret)
+	case StateBackendGCS:
+		return newGCSStateStore(t.client, t.logger, t.config, varsSecret)
+	case StateBackendAzureBlob:
+		return newAzureBlobStateStore(t.client, t.logger, t.config, varsSecret)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", t.config.StateBackend)
+	}
+}
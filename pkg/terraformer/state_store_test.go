@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// fakeBlobClient is an in-memory blobClient, so blobStateStore can be contract-tested without a real S3/GCS/Azure
+// dependency.
+type fakeBlobClient struct {
+	objects map[string][]byte
+}
+
+func newFakeBlobClient() *fakeBlobClient {
+	return &fakeBlobClient{objects: map[string][]byte{}}
+}
+
+func (c *fakeBlobClient) GetObject(_ context.Context, key string) ([]byte, bool, error) {
+	data, ok := c.objects[key]
+	return data, ok, nil
+}
+
+func (c *fakeBlobClient) PutObject(_ context.Context, key string, data []byte) error {
+	c.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+// This suite exercises every StateStore implementation against the same contract, so that swapping
+// Config.StateBackend never changes the "state is empty"/"state key is not available" semantics Run relies on.
+var _ = Describe("StateStore", func() {
+	const namespace, name = "test", "state"
+
+	for _, backend := range []struct {
+		name     string
+		newStore func(client.Client) StateStore
+	}{
+		{"configmap", func(c client.Client) StateStore {
+			return newConfigMapStateStore(c, zap.New(zap.UseDevMode(true)), namespace, name)
+		}},
+		{"blob", func(c client.Client) StateStore {
+			return newBlobStateStore(c, zap.New(zap.UseDevMode(true)), namespace, name, newFakeBlobClient())
+		}},
+		{"s3", func(c client.Client) StateStore {
+			restore := newS3Client
+			newS3Client = func(*Config, *corev1.Secret) (blobClient, error) { return newFakeBlobClient(), nil }
+			defer func() { newS3Client = restore }()
+
+			store, err := newS3StateStore(c, zap.New(zap.UseDevMode(true)), &Config{StateBackendBucketName: "bucket", Namespace: namespace, StateConfigMapName: name}, &corev1.Secret{})
+			Expect(err).NotTo(HaveOccurred())
+			return store
+		}},
+		{"gcs", func(c client.Client) StateStore {
+			restore := newGCSClient
+			newGCSClient = func(*Config, *corev1.Secret) (blobClient, error) { return newFakeBlobClient(), nil }
+			defer func() { newGCSClient = restore }()
+
+			store, err := newGCSStateStore(c, zap.New(zap.UseDevMode(true)), &Config{StateBackendBucketName: "bucket", Namespace: namespace, StateConfigMapName: name}, &corev1.Secret{})
+			Expect(err).NotTo(HaveOccurred())
+			return store
+		}},
+		{"azureblob", func(c client.Client) StateStore {
+			restore := newAzureBlobClient
+			newAzureBlobClient = func(*Config, *corev1.Secret) (blobClient, error) { return newFakeBlobClient(), nil }
+			defer func() { newAzureBlobClient = restore }()
+
+			store, err := newAzureBlobStateStore(c, zap.New(zap.UseDevMode(true)), &Config{StateBackendBucketName: "bucket", StateBackendAzureStorageAccount: "account", Namespace: namespace, StateConfigMapName: name}, &corev1.Secret{})
+			Expect(err).NotTo(HaveOccurred())
+			return store
+		}},
+	} {
+		backend := backend
+
+		Describe(backend.name, func() {
+			var (
+				fakeClient client.Client
+				store      StateStore
+			)
+
+			BeforeEach(func() {
+				fakeClient = fake.NewClientBuilder().Build()
+
+				cm := &corev1.ConfigMap{}
+				cm.Namespace = namespace
+				cm.Name = name
+				Expect(fakeClient.Create(context.Background(), cm)).To(Succeed())
+
+				store = backend.newStore(fakeClient)
+			})
+
+			It("should return nil state before anything was stored", func() {
+				state, err := store.Fetch(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(isStateEmpty(state)).To(BeTrue())
+			})
+
+			It("should round-trip stored state", func() {
+				Expect(store.Store(context.Background(), []byte(`{"version":4}`))).To(Succeed())
+
+				state, err := store.Fetch(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(state).To(Equal([]byte(`{"version":4}`)))
+				Expect(isStateEmpty(state)).To(BeFalse())
+			})
+
+			It("should add and remove the finalizer on the backing configmap", func() {
+				Expect(store.EnsureFinalizer(context.Background())).To(Succeed())
+
+				cm := &corev1.ConfigMap{}
+				Expect(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, cm)).To(Succeed())
+				Expect(cm.Finalizers).To(ContainElement(TerraformerFinalizer))
+
+				Expect(store.RemoveFinalizer(context.Background())).To(Succeed())
+				Expect(fakeClient.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, cm)).To(Succeed())
+				Expect(cm.Finalizers).NotTo(ContainElement(TerraformerFinalizer))
+			})
+
+			It("should error if the backing configmap does not exist", func() {
+				cm := &corev1.ConfigMap{}
+				cm.Namespace = namespace
+				cm.Name = name
+				Expect(fakeClient.Delete(context.Background(), cm)).To(Succeed())
+
+				_, err := store.Fetch(context.Background())
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	}
+})
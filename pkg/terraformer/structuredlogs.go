@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package terraformer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// structuredLogMessage mirrors (the subset we care about of) a single line of terraform's `-json` log stream, as
+// documented at https://www.terraform.io/internals/machine-readable-ui.
+type structuredLogMessage struct {
+	Level   string `json:"@level"`
+	Message string `json:"@message"`
+	Module  string `json:"@module"`
+	Type    string `json:"type"`
+
+	Hook struct {
+		Resource struct {
+			Addr   string `json:"addr"`
+			Action string `json:"action"`
+		} `json:"resource"`
+		Elapsed float64 `json:"elapsed_seconds"`
+	} `json:"hook"`
+
+	Diagnostic *structuredDiagnostic `json:"diagnostic"`
+}
+
+// structuredDiagnostic mirrors the `diagnostic` field of a structured log message of type "diagnostic".
+type structuredDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Address  string `json:"address"`
+}
+
+// parseStructuredLogLine parses a single line of terraform's `-json` output. The second return value is false if
+// the line is not valid structured log JSON (e.g. empty lines or output terraform itself writes outside of
+// -json, such as a crash log), in which case callers should fall back to treating it as plain text.
+func parseStructuredLogLine(line string) (structuredLogMessage, bool) {
+	var msg structuredLogMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return structuredLogMessage{}, false
+	}
+	return msg, true
+}
+
+// logStructuredMessage re-emits a parsed structured log message through the Terraformer's logger, with
+// key/value fields instead of opaque text, and returns a diagnostic if the message carried one.
+func (t *Terraformer) logStructuredMessage(msg structuredLogMessage) *structuredDiagnostic {
+	keysAndValues := []interface{}{"module", msg.Module, "type", msg.Type}
+	if msg.Hook.Resource.Addr != "" {
+		keysAndValues = append(keysAndValues, "resource", msg.Hook.Resource.Addr, "action", msg.Hook.Resource.Action)
+	}
+	if msg.Hook.Elapsed != 0 {
+		keysAndValues = append(keysAndValues, "elapsedSeconds", msg.Hook.Elapsed)
+	}
+
+	switch msg.Level {
+	case "error":
+		t.logger.Error(errors.New(msg.Message), "terraform", keysAndValues...)
+	case "warn":
+		t.logger.Info(msg.Message, append(keysAndValues, "level", msg.Level)...)
+	default:
+		t.logger.Info(msg.Message, keysAndValues...)
+	}
+
+	return msg.Diagnostic
+}
+
+// renderDiagnostics renders the given diagnostics into a compact, human-readable multi-line summary suitable for
+// the termination message file, instead of dumping raw structured-log JSON there.
+func renderDiagnostics(diagnostics []*structuredDiagnostic) string {
+	if len(diagnostics) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	for _, d := range diagnostics {
+		address := d.Address
+		if address == "" {
+			address = "<root module>"
+		}
+		fmt.Fprintf(&out, "[%s] %s: %s\n", strings.ToUpper(d.Severity), address, d.Summary)
+		if d.Detail != "" {
+			fmt.Fprintf(&out, "  %s\n", d.Detail)
+		}
+	}
+	return out.String()
+}
+
+// commandSupportsJSON reports whether the given terraform subcommand understands the `-json` flag. `terraform
+// state ...` subcommands do not.
+func commandSupportsJSON(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "init", "plan", "apply", "destroy", "validate":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package terraformer drives a local `terraform` binary on behalf of a Gardener extension: it fetches
+// configuration, variables and state from Kubernetes objects, runs terraform against them, and persists the
+// result back.
+package terraformer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Command is a terraform subcommand that can be requested via Terraformer.Run.
+type Command string
+
+const (
+	// Init runs `terraform init`. It is always executed as a prerequisite of the other commands and cannot be
+	// requested directly.
+	Init Command = "init"
+	// Plan runs `terraform plan`.
+	Plan Command = "plan"
+	// Apply runs `terraform apply`.
+	Apply Command = "apply"
+	// Destroy runs `terraform destroy`.
+	Destroy Command = "destroy"
+	// Validate runs `terraform validate` followed by `terraform plan`.
+	Validate Command = "validate"
+)
+
+// TerraformerFinalizer is added to the configuration, state and variables objects for as long as terraformer is
+// working with them, so that they cannot be removed concurrently.
+const TerraformerFinalizer = "terraformer.gardener.cloud/cleanup"
+
+var (
+	// TerraformBinary is the path to the terraform binary. It is a variable so tests can point it to a fake.
+	TerraformBinary = "/terraform"
+	// Stderr is where terraform output lines that are not parsed as structured log messages, and the rendered
+	// diagnostics summary, are copied to. Parsed structured log messages go to the logger only.
+	Stderr io.Writer = os.Stderr
+	// SignalNotify registers the given channel to be notified of the given signals. It is a variable so tests
+	// can intercept it instead of relying on real OS signals.
+	SignalNotify = signal.Notify
+)
+
+// Config bundles everything needed to create a Terraformer.
+type Config struct {
+	// Namespace is the namespace the configuration/state/variables objects live in.
+	Namespace string
+	// RESTConfig is used to build the Kubernetes client if none is injected directly.
+	RESTConfig *rest.Config
+
+	// ConfigurationConfigMapName is the ConfigMap containing the terraform configuration (main.tf et al.).
+	ConfigurationConfigMapName string
+	// StateConfigMapName is the ConfigMap the terraform state is read from and persisted to.
+	StateConfigMapName string
+	// VariablesSecretName is the Secret containing the terraform variables (e.g. provider credentials).
+	VariablesSecretName string
+
+	// StructuredLogs makes terraformer pass `-json` to terraform and parse the resulting NDJSON stream instead
+	// of copying raw terraform output. Each parsed message is re-emitted through the injected logger with
+	// structured fields (resource address, action, elapsed time, ...).
+	StructuredLogs bool
+
+	// JUnitReportPath, if set, makes Run(Validate) write a JUnit XML report of the validate/plan diagnostics to
+	// this path, so CI systems can consume it as a test result. NewTerraformer copies it into
+	// PathSet.JUnitReportPath, replacing whatever the PathSet held.
+	JUnitReportPath string
+
+	// ExitTimeout is how long terraformer waits for the terraform child process to exit gracefully after
+	// forwarding SIGINT to it, before escalating to SIGKILL. Defaults to DefaultExitTimeout.
+	ExitTimeout time.Duration
+
+	// PlanConfigMapName is the ConfigMap Run(Plan) persists its plan artifact and change summary to, and
+	// Run(Apply) reads it from when UsePlan is set.
+	PlanConfigMapName string
+	// UsePlan makes Run(Apply) apply the plan persisted in PlanConfigMapName instead of re-planning, so the
+	// applied changeset is guaranteed to match what was reviewed.
+	UsePlan bool
+
+	// OutputsSecretName is the Secret Run(Apply) persists `terraform output -json` into, if set. Every output is
+	// stored here, not only sensitive ones, so the Secret is always a complete record of the outputs.
+	OutputsSecretName string
+	// OutputsConfigMapName additionally mirrors the non-sensitive outputs into a ConfigMap, if set, so consumers
+	// that only need public values (e.g. a VPC ID) don't need RBAC on the Secret.
+	OutputsConfigMapName string
+
+	// StateBackend selects the StateStore implementation Run uses to read and persist terraform state. Defaults
+	// to StateBackendConfigMap, which stores the full state inline in StateConfigMapName.
+	StateBackend StateBackend
+	// StateBackendBucketName is the bucket (S3/GCS) or container (Azure Blob) terraform state is stored in when
+	// StateBackend is not StateBackendConfigMap. Unused for StateBackendConfigMap.
+	StateBackendBucketName string
+	// StateBackendAzureStorageAccount is the storage account StateBackendBucketName lives in. Only used for
+	// StateBackendAzureBlob.
+	StateBackendAzureStorageAccount string
+}
+
+// Terraformer executes terraform commands against configuration, variables and state read from Kubernetes
+// objects, and persists the resulting state back.
+type Terraformer struct {
+	config *Config
+	logger logr.Logger
+	paths  *PathSet
+	clock  clock.Clock
+
+	client client.Client
+}
+
+// NewTerraformer creates a new Terraformer, building its own Kubernetes client from config.RESTConfig.
+func NewTerraformer(config *Config, logger logr.Logger, paths *PathSet, clock clock.Clock) (*Terraformer, error) {
+	c, err := client.New(config.RESTConfig, client.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("could not create kubernetes client: %w", err)
+	}
+
+	if config.JUnitReportPath != "" {
+		paths.JUnitReportPath = config.JUnitReportPath
+	}
+
+	if config.ExitTimeout == 0 {
+		config.ExitTimeout = DefaultExitTimeout
+	}
+
+	return &Terraformer{
+		config: config,
+		logger: logger,
+		paths:  paths,
+		clock:  clock,
+		client: c,
+	}, nil
+}
+
+// NewDefaultTerraformer creates a new Terraformer with the default paths, a real clock and a logger suitable for
+// production use.
+func NewDefaultTerraformer(config *Config) (*Terraformer, error) {
+	return NewTerraformer(config, defaultLogger(), DefaultPaths(), clock.RealClock{})
+}
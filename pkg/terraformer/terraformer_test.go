@@ -12,11 +12,13 @@ import (
 	"os"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gardener/gardener/pkg/utils/test"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -94,9 +96,6 @@ var _ = Describe("Terraformer", func() {
 			It("should not allow to run Init directly", func() {
 				Expect(tf.Run(terraformer.Init)).To(MatchError(ContainSubstring("not supported")))
 			})
-			It("should not allow to run Plan directly", func() {
-				Expect(tf.Run(terraformer.Plan)).To(MatchError(ContainSubstring("not supported")))
-			})
 			It("should fail if config can't be fetched", func() {
 				Expect(testClient.Delete(ctx, testObjs.ConfigurationConfigMap)).To(Succeed())
 				Expect(tf.Run(terraformer.Apply)).To(MatchError(ContainSubstring("not found")))
@@ -456,6 +455,383 @@ var _ = Describe("Terraformer", func() {
 			})
 		})
 
+		Context("plan support", func() {
+			var (
+				planConfigMap *corev1.ConfigMap
+				resetBinary   func()
+			)
+
+			BeforeEach(func() {
+				var err error
+
+				planConfigMap = &corev1.ConfigMap{}
+				planConfigMap.Namespace = testObjs.Namespace
+				planConfigMap.Name = "plan"
+
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						PlanConfigMapName:          planConfigMap.Name,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should run Plan successfully and persist the plan configmap", func() {
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("args: plan -out="))
+				Eventually(logBuffer).Should(gbytes.Say("args: show -json"))
+				Eventually(logBuffer).Should(gbytes.Say("successfully stored terraform plan"))
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(planConfigMap), planConfigMap)).To(Succeed())
+				Expect(planConfigMap.Data["plan"]).NotTo(BeEmpty())
+				Expect(planConfigMap.Data["summary.json"]).NotTo(BeEmpty())
+				Expect(planConfigMap.Data["config-hash"]).NotTo(BeEmpty())
+				Expect(planConfigMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+			})
+
+			It("should apply the persisted plan instead of re-planning when UsePlan is set", func() {
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+
+				var err error
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						PlanConfigMapName:          planConfigMap.Name,
+						UsePlan:                    true,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say(fmt.Sprintf("args: apply %s", paths.PlanFile)))
+				Eventually(logBuffer).ShouldNot(gbytes.Say("args: apply -auto-approve"))
+			})
+
+			It("should reject applying a plan that is stale because the configuration changed", func() {
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+
+				testObjs.Refresh()
+				changedConfig := testObjs.ConfigurationConfigMap
+				changedConfig.Data["main.tf"] = "# changed configuration\n"
+				Expect(testClient.Update(ctx, changedConfig)).To(Succeed())
+
+				var err error
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						PlanConfigMapName:          planConfigMap.Name,
+						UsePlan:                    true,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(tf.Run(terraformer.Apply)).To(MatchError(ContainSubstring("stale")))
+			})
+
+			It("should remove the plan configmap's finalizer on Destroy", func() {
+				Expect(tf.Run(terraformer.Plan)).To(Succeed())
+
+				testObjs.Refresh()
+				nonEmptyState := testObjs.StateConfigMap
+				nonEmptyState.Data = map[string]string{"terraform.tfstate": `{"version": 4}`}
+				Expect(testClient.Update(ctx, nonEmptyState)).To(Succeed())
+
+				Expect(tf.Run(terraformer.Destroy)).To(Succeed())
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(planConfigMap), planConfigMap)).To(Succeed())
+				Expect(planConfigMap.Finalizers).To(Not(ContainElement(terraformer.TerraformerFinalizer)))
+			})
+		})
+
+		Context("outputs support", func() {
+			var (
+				outputsSecret    *corev1.Secret
+				outputsConfigMap *corev1.ConfigMap
+				resetBinary      func()
+			)
+
+			BeforeEach(func() {
+				var err error
+
+				outputsSecret = &corev1.Secret{}
+				outputsSecret.Namespace = testObjs.Namespace
+				outputsSecret.Name = "outputs"
+
+				outputsConfigMap = &corev1.ConfigMap{}
+				outputsConfigMap.Namespace = testObjs.Namespace
+				outputsConfigMap.Name = "outputs"
+
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						OutputsSecretName:          outputsSecret.Name,
+						OutputsConfigMapName:       outputsConfigMap.Name,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should run Apply successfully and persist the outputs", func() {
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("args: output -json"))
+				Eventually(logBuffer).Should(gbytes.Say("successfully stored terraform outputs"))
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(outputsSecret), outputsSecret)).To(Succeed())
+				Expect(outputsSecret.Data).To(HaveKeyWithValue("fake_output", []byte("fake-value")))
+				Expect(outputsSecret.Data).To(HaveKeyWithValue("fake_secret", []byte("fake-secret-value")))
+				Expect(outputsSecret.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(outputsConfigMap), outputsConfigMap)).To(Succeed())
+				Expect(outputsConfigMap.Data).To(HaveKeyWithValue("fake_output", "fake-value"))
+				Expect(outputsConfigMap.Data).NotTo(HaveKey("fake_secret"))
+				Expect(outputsConfigMap.Finalizers).To(ContainElement(terraformer.TerraformerFinalizer))
+			})
+
+			It("should remove the outputs secret's and configmap's finalizer on Destroy", func() {
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+
+				testObjs.Refresh()
+				nonEmptyState := testObjs.StateConfigMap
+				nonEmptyState.Data = map[string]string{"terraform.tfstate": `{"version": 4}`}
+				Expect(testClient.Update(ctx, nonEmptyState)).To(Succeed())
+
+				Expect(tf.Run(terraformer.Destroy)).To(Succeed())
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(outputsSecret), outputsSecret)).To(Succeed())
+				Expect(outputsSecret.Finalizers).To(Not(ContainElement(terraformer.TerraformerFinalizer)))
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(outputsConfigMap), outputsConfigMap)).To(Succeed())
+				Expect(outputsConfigMap.Finalizers).To(Not(ContainElement(terraformer.TerraformerFinalizer)))
+			})
+
+			It("should persist partial outputs after a failed apply", func() {
+				resetBinary()
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteExitCodeForCommands("apply", "42"),
+				)
+				resetBinary = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+				)
+
+				err := tf.Run(terraformer.Apply)
+				Expect(err).To(MatchError(ContainSubstring("terraform command failed")))
+
+				Eventually(logBuffer).Should(gbytes.Say("args: output -json"))
+				Eventually(logBuffer).Should(gbytes.Say("successfully stored terraform outputs"))
+
+				Expect(testClient.Get(ctx, testutils.ObjectKeyFromObject(outputsSecret), outputsSecret)).To(Succeed())
+				Expect(outputsSecret.Data).To(HaveKeyWithValue("fake_output", []byte("fake-value")))
+			})
+		})
+
+		Context("structured logs support", func() {
+			const hookLine = `{"@level":"info","@message":"aws_instance.foo: Creation complete after 3s","@module":"terraform.ui","type":"apply_complete","hook":{"resource":{"addr":"aws_instance.foo","action":"create"},"elapsed_seconds":3}}`
+			const diagnosticLine = `{"@level":"error","@message":"error creating instance","@module":"terraform.ui","type":"diagnostic","diagnostic":{"severity":"error","summary":"could not create instance","detail":"AccessDenied: not authorized","address":"aws_instance.foo"}}`
+
+			var resetBinary func()
+
+			BeforeEach(func() {
+				var err error
+
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						StructuredLogs:             true,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should re-emit hook messages as structured log fields instead of raw JSON", func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteJSONOutput("apply", hookLine),
+				)
+				resetBinary = test.WithVars(&terraformer.TerraformBinary, fakeTerraform.Path)
+
+				Expect(tf.Run(terraformer.Apply)).To(Succeed())
+				Eventually(logBuffer).Should(gbytes.Say("args: apply.*-json"))
+				Eventually(logBuffer).Should(gbytes.Say("aws_instance.foo: Creation complete after 3s"))
+				Eventually(logBuffer).Should(gbytes.Say("action.*create"))
+				Eventually(logBuffer).ShouldNot(gbytes.Say(`"@level"`))
+			})
+
+			It("should render diagnostics into the termination message instead of raw JSON on failure", func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCodeForCommands("init", "0", "apply", "42"),
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteJSONOutput("apply", diagnosticLine),
+				)
+				resetBinary = test.WithVars(&terraformer.TerraformBinary, fakeTerraform.Path)
+
+				err := tf.Run(terraformer.Apply)
+				Expect(err).To(MatchError(ContainSubstring("terraform command failed")))
+
+				Expect(paths.TerminationMessagePath).To(testutils.BeFileWithContents(And(
+					ContainSubstring("[ERROR] aws_instance.foo: could not create instance"),
+					ContainSubstring("AccessDenied: not authorized"),
+					Not(ContainSubstring(`"@level"`)),
+				)), "termination message should contain a rendered summary, not raw JSON")
+			})
+		})
+
+		Context("JUnit reporting support", func() {
+			const warningLine = `{"@level":"warn","@message":"deprecated attribute","@module":"terraform.ui","type":"diagnostic","diagnostic":{"severity":"warning","summary":"attribute is deprecated","detail":"use new_attr instead","address":"aws_instance.foo"}}`
+			const errorLine = `{"@level":"error","@message":"invalid reference","@module":"terraform.ui","type":"diagnostic","diagnostic":{"severity":"error","summary":"invalid reference","detail":"undeclared resource","address":"aws_instance.bar"}}`
+
+			var (
+				junitReportPath string
+				resetBinary     func()
+			)
+
+			BeforeEach(func() {
+				var err error
+
+				junitReportPath = baseDir + "/junit.xml"
+
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						JUnitReportPath:            junitReportPath,
+						RESTConfig:                 restConfig,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					clock.RealClock{},
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				resetBinary()
+			})
+
+			It("should write a single passing testcase when validate succeeds without diagnostics", func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+				resetBinary = test.WithVars(&terraformer.TerraformBinary, fakeTerraform.Path)
+
+				Expect(tf.Run(terraformer.Validate)).To(Succeed())
+
+				Expect(junitReportPath).To(testutils.BeFileWithContents(And(
+					ContainSubstring(`tests="1"`),
+					ContainSubstring(`failures="0"`),
+					ContainSubstring(`skipped="0"`),
+					ContainSubstring(`name="root module"`),
+				)))
+			})
+
+			It("should map error and warning diagnostics to failure and skipped testcases", func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("50ms"),
+					testutils.OverwriteJSONOutput("validate", errorLine),
+					testutils.OverwriteJSONOutput("plan", warningLine),
+				)
+				resetBinary = test.WithVars(&terraformer.TerraformBinary, fakeTerraform.Path)
+
+				Expect(tf.Run(terraformer.Validate)).To(Succeed())
+
+				Expect(junitReportPath).To(testutils.BeFileWithContents(And(
+					ContainSubstring(`tests="2"`),
+					ContainSubstring(`failures="1"`),
+					ContainSubstring(`skipped="1"`),
+					ContainSubstring("invalid reference"),
+					ContainSubstring("attribute is deprecated"),
+					ContainSubstring(`name="terraform.version" value=`),
+				)))
+			})
+
+			It("should emit a failing testcase instead of a false-green report when validate/plan fails without a diagnostic", func() {
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCodeForCommands("init", "0", "validate", "0", "plan", "45"),
+					testutils.OverwriteSleepDuration("50ms"),
+				)
+				resetBinary = test.WithVars(&terraformer.TerraformBinary, fakeTerraform.Path)
+
+				err := tf.Run(terraformer.Validate)
+				Expect(err).To(MatchError(ContainSubstring("terraform command failed")))
+
+				Expect(junitReportPath).To(testutils.BeFileWithContents(And(
+					ContainSubstring(`tests="1"`),
+					ContainSubstring(`failures="1"`),
+					ContainSubstring(`name="root module"`),
+				)), "a plain-text terraform failure with no parsed diagnostic must not be reported as a pass")
+			})
+		})
+
 		Describe("signal handling", func() {
 			var (
 				signalCh chan<- os.Signal
@@ -531,5 +907,75 @@ var _ = Describe("Terraformer", func() {
 				wg.Done()
 			}, 1)
 		})
+
+		Describe("force-kill on ExitTimeout", func() {
+			var (
+				fakeClock *clock.FakeClock
+				signalCh  chan<- os.Signal
+			)
+
+			BeforeEach(func() {
+				var err error
+
+				fakeTerraform = testutils.NewFakeTerraform(
+					testutils.OverwriteExitCode("0"),
+					testutils.OverwriteSleepDuration("2s"),
+					testutils.OverwriteIgnoreSignals(),
+				)
+
+				resetVars = test.WithVars(
+					&terraformer.TerraformBinary, fakeTerraform.Path,
+					&terraformer.SignalNotify, func(c chan<- os.Signal, sig ...os.Signal) {
+						Expect(sig).To(ConsistOf(syscall.SIGINT, syscall.SIGTERM))
+						signalCh = c
+					},
+				)
+
+				fakeClock = clock.NewFakeClock(time.Now())
+
+				tf, err = terraformer.NewTerraformer(
+					&terraformer.Config{
+						Namespace:                  testObjs.Namespace,
+						ConfigurationConfigMapName: testObjs.ConfigurationConfigMap.Name,
+						StateConfigMapName:         testObjs.StateConfigMap.Name,
+						VariablesSecretName:        testObjs.VariablesSecret.Name,
+						RESTConfig:                 restConfig,
+						ExitTimeout:                time.Second,
+					},
+					zap.New(zap.UseDevMode(true), zap.WriteTo(multiWriter)),
+					paths,
+					fakeClock,
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should escalate to SIGKILL if terraform ignores SIGINT past ExitTimeout", func(done Done) {
+				go func() {
+					defer GinkgoRecover()
+
+					err := tf.Run(terraformer.Apply)
+					Expect(err).To(HaveOccurred())
+
+					var withExitCode utils.WithExitCode
+					Expect(errors.As(err, &withExitCode)).To(BeTrue())
+					Expect(withExitCode.ExitCode()).To(Equal(137))
+
+					Expect(paths.TerminationMessagePath).To(testutils.BeFileWithContents(
+						ContainSubstring("forced termination"),
+					))
+					close(done)
+				}()
+
+				Eventually(logBuffer).Should(gbytes.Say("some terraform output"))
+				Eventually(logBuffer).Should(gbytes.Say("doing some long running IaaS ops"), "should run terraform apply")
+				signalCh <- syscall.SIGINT
+				Eventually(logBuffer).Should(gbytes.Say(fmt.Sprintf("fake terraform received signal: %s", syscall.SIGINT.String())))
+
+				Eventually(func() bool {
+					return fakeClock.HasWaiters()
+				}).Should(BeTrue())
+				fakeClock.Step(time.Second)
+			}, 5)
+		})
 	})
 })
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import "fmt"
+
+// WithExitCode is implemented by errors that carry the exit code of a process that failed.
+type WithExitCode interface {
+	error
+	// ExitCode returns the exit code of the process that caused the error.
+	ExitCode() int
+}
+
+type withExitCode struct {
+	cause error
+	code  int
+}
+
+// NewWithExitCode wraps the given error, attaching the given process exit code to it.
+func NewWithExitCode(cause error, code int) WithExitCode {
+	return &withExitCode{cause: cause, code: code}
+}
+
+func (w *withExitCode) Error() string {
+	return fmt.Sprintf("%s (exit code %d)", w.cause.Error(), w.code)
+}
+
+func (w *withExitCode) Unwrap() error {
+	return w.cause
+}
+
+func (w *withExitCode) ExitCode() int {
+	return w.code
+}
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import "sync"
+
+// CleanupActionHandle identifies a registered cleanup action so it can be removed again via RemoveCleanupAction.
+type CleanupActionHandle *func()
+
+var (
+	cleanupActionsLock sync.Mutex
+	cleanupActions     = map[CleanupActionHandle]func(){}
+)
+
+// AddCleanupAction registers an action to be run by RunCleanupActions, e.g. in an AfterEach/AfterSuite block.
+func AddCleanupAction(action func()) CleanupActionHandle {
+	cleanupActionsLock.Lock()
+	defer cleanupActionsLock.Unlock()
+
+	handle := CleanupActionHandle(&action)
+	cleanupActions[handle] = action
+	return handle
+}
+
+// RemoveCleanupAction unregisters a previously added cleanup action.
+func RemoveCleanupAction(handle CleanupActionHandle) {
+	cleanupActionsLock.Lock()
+	defer cleanupActionsLock.Unlock()
+
+	delete(cleanupActions, handle)
+}
+
+// RunCleanupActions runs all currently registered cleanup actions. It does not unregister them: an action stays
+// registered until RemoveCleanupAction is called with its handle.
+func RunCleanupActions() {
+	cleanupActionsLock.Lock()
+	actions := make([]func(), 0, len(cleanupActions))
+	for _, action := range cleanupActions {
+		actions = append(actions, action)
+	}
+	cleanupActionsLock.Unlock()
+
+	for _, action := range actions {
+		action()
+	}
+}
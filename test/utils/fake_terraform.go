@@ -0,0 +1,182 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/gomega" //nolint:revive,stylecheck
+)
+
+// FakeTerraform wraps a fake `terraform` binary (a small bash script) that can be pointed to via
+// terraformer.TerraformBinary in tests, so no real terraform/cloud provider is required to exercise Terraformer.
+type FakeTerraform struct {
+	// Path is the filesystem path of the generated fake binary.
+	Path string
+}
+
+type fakeTerraformOptions struct {
+	exitCode            string
+	exitCodeByCommand   map[string]string
+	sleepDuration       string
+	ignoreSignals       bool
+	jsonOutputByCommand map[string]string
+}
+
+// Overwrite customizes the behaviour of a FakeTerraform created via NewFakeTerraform.
+type Overwrite func(*fakeTerraformOptions)
+
+// OverwriteExitCode makes the fake terraform binary exit with the given code, regardless of the subcommand.
+func OverwriteExitCode(code string) Overwrite {
+	return func(o *fakeTerraformOptions) {
+		o.exitCode = code
+	}
+}
+
+// OverwriteExitCodeForCommands makes the fake terraform binary exit with a different code per subcommand. The
+// argument is a flat list of alternating subcommand/exit-code pairs, e.g. "apply", "42", "destroy", "43".
+func OverwriteExitCodeForCommands(pairs ...string) Overwrite {
+	return func(o *fakeTerraformOptions) {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			o.exitCodeByCommand[pairs[i]] = pairs[i+1]
+		}
+	}
+}
+
+// OverwriteSleepDuration makes the fake terraform binary sleep for the given duration (a Go duration string, e.g.
+// "50ms", passed to `sleep` in seconds) while "doing some long running IaaS ops", before exiting.
+func OverwriteSleepDuration(duration string) Overwrite {
+	return func(o *fakeTerraformOptions) {
+		o.sleepDuration = duration
+	}
+}
+
+// OverwriteIgnoreSignals makes the fake terraform binary ignore SIGINT/SIGTERM instead of exiting, so tests can
+// exercise a force-kill (SIGKILL) fallback.
+func OverwriteIgnoreSignals() Overwrite {
+	return func(o *fakeTerraformOptions) {
+		o.ignoreSignals = true
+	}
+}
+
+// OverwriteJSONOutput makes the fake terraform binary, when invoked with the `-json` flag, emit the given NDJSON
+// lines to stdout for the given subcommand instead of its regular plain-text output, so tests can exercise
+// structured log parsing and JUnit reporting end to end.
+func OverwriteJSONOutput(command, ndjson string) Overwrite {
+	return func(o *fakeTerraformOptions) {
+		o.jsonOutputByCommand[command] = ndjson
+	}
+}
+
+// NewFakeTerraform writes a new fake terraform binary to a temporary file and returns it. The file is registered
+// for removal via AddCleanupAction.
+func NewFakeTerraform(overwrites ...Overwrite) FakeTerraform {
+	options := &fakeTerraformOptions{
+		exitCode:            "0",
+		exitCodeByCommand:   map[string]string{},
+		sleepDuration:       "0s",
+		jsonOutputByCommand: map[string]string{},
+	}
+	for _, overwrite := range overwrites {
+		overwrite(options)
+	}
+
+	sleepDuration, err := time.ParseDuration(options.sleepDuration)
+	Expect(err).NotTo(HaveOccurred())
+
+	f, err := ioutil.TempFile("", "fake-terraform-*")
+	Expect(err).NotTo(HaveOccurred())
+	// the file must not stay open for writing, otherwise executing it fails with "text file busy"
+	Expect(f.Close()).To(Succeed())
+
+	var exitCodeCases strings.Builder
+	for command, code := range options.exitCodeByCommand {
+		fmt.Fprintf(&exitCodeCases, "  %s) EXIT_CODE=%s ;;\n", command, code)
+	}
+
+	var jsonOutputCases strings.Builder
+	for command, ndjson := range options.jsonOutputByCommand {
+		fmt.Fprintf(&jsonOutputCases, "    %s)\n      cat <<'FAKE_JSON_EOF'\n%s\nFAKE_JSON_EOF\n      ;;\n", command, ndjson)
+	}
+
+	trap := `trap 'echo "fake terraform received signal: interrupt"; exit 0' INT TERM`
+	if options.ignoreSignals {
+		trap = `trap 'echo "fake terraform received signal: interrupt"' INT TERM`
+	}
+
+	script := fmt.Sprintf(`#!/usr/bin/env bash
+set -uo pipefail
+
+%s
+
+echo "args: $*"
+
+EXIT_CODE=%s
+case "$1" in
+%s
+esac
+
+if [ "$1" = "output" ]; then
+  if [ "$EXIT_CODE" = "0" ]; then
+    echo '{"fake_output":{"sensitive":false,"value":"fake-value"},"fake_secret":{"sensitive":true,"value":"fake-secret-value"}}'
+  else
+    echo "some terraform error" >&2
+  fi
+  exit "$EXIT_CODE"
+fi
+
+JSON_MODE=""
+for arg in "$@"; do
+  if [ "$arg" = "-json" ]; then JSON_MODE=1; fi
+done
+
+if [ -n "$JSON_MODE" ]; then
+  case "$1" in
+%s
+    *) echo "some terraform output" ;;
+  esac
+else
+  echo "some terraform output"
+fi
+
+case "$1" in
+  apply|destroy|validate|plan)
+    echo "doing some long running IaaS ops"
+    sleep %s &
+    SLEEP_PID=$!
+    while kill -0 "$SLEEP_PID" 2>/dev/null; do wait "$SLEEP_PID"; done
+    ;;
+esac
+
+if [ "$1" = "plan" ] && [ "$EXIT_CODE" = "0" ]; then
+  for arg in "$@"; do
+    case "$arg" in
+      -out=*) echo '{"fake":"plan"}' > "${arg#-out=}" ;;
+    esac
+  done
+fi
+
+if [ "$EXIT_CODE" != "0" ]; then
+  echo "some terraform error" >&2
+fi
+
+exit "$EXIT_CODE"
+`, trap, options.exitCode, exitCodeCases.String(), jsonOutputCases.String(), strconv.FormatFloat(sleepDuration.Seconds(), 'f', -1, 64))
+
+	Expect(ioutil.WriteFile(f.Name(), []byte(script), 0755)).To(Succeed())
+
+	var handle CleanupActionHandle
+	handle = AddCleanupAction(func() {
+		defer RemoveCleanupAction(handle)
+		_ = os.Remove(f.Name())
+	})
+
+	return FakeTerraform{Path: f.Name()}
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/onsi/gomega/types"
+)
+
+// BeEmptyFile succeeds if the actual value is a path to a file that is empty or does not exist.
+func BeEmptyFile() types.GomegaMatcher {
+	return &fileContentsMatcher{expected: nil, expectEmpty: true}
+}
+
+// BeFileWithContents succeeds if the actual value is a path to a file whose contents match the given matcher.
+func BeFileWithContents(contentsMatcher types.GomegaMatcher) types.GomegaMatcher {
+	return &fileContentsMatcher{expected: contentsMatcher}
+}
+
+type fileContentsMatcher struct {
+	expected    types.GomegaMatcher
+	expectEmpty bool
+}
+
+func (m *fileContentsMatcher) Match(actual interface{}) (bool, error) {
+	path, ok := actual.(string)
+	if !ok {
+		return false, fmt.Errorf("BeFileWithContents matcher expects a file path (string), got %T", actual)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m.expectEmpty, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if m.expectEmpty {
+		return len(content) == 0, nil
+	}
+	return m.expected.Match(string(content))
+}
+
+func (m *fileContentsMatcher) FailureMessage(actual interface{}) string {
+	if m.expectEmpty {
+		return fmt.Sprintf("Expected\n\t%#v\nto be an empty file", actual)
+	}
+	return fmt.Sprintf("Expected\n\t%#v\nto be a file with matching contents", actual)
+}
+
+func (m *fileContentsMatcher) NegatedFailureMessage(actual interface{}) string {
+	if m.expectEmpty {
+		return fmt.Sprintf("Expected\n\t%#v\nnot to be an empty file", actual)
+	}
+	return fmt.Sprintf("Expected\n\t%#v\nnot to be a file with matching contents", actual)
+}
@@ -0,0 +1,97 @@
+// SPDX-FileCopyrightText: 2020 SAP SE or an SAP affiliate company and Gardener contributors
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/gomega" //nolint:revive,stylecheck
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TestObjects bundles the Kubernetes objects a Terraformer needs (configuration, state, variables) for use in
+// ginkgo specs, along with the client and namespace they live in so specs can mutate and Refresh them.
+type TestObjects struct {
+	client client.Client
+	ctx    context.Context
+
+	Namespace              string
+	ConfigurationConfigMap *corev1.ConfigMap
+	StateConfigMap         *corev1.ConfigMap
+	VariablesSecret        *corev1.Secret
+}
+
+// PrepareTestObjects creates a namespace, whose generated name carries the given prefix, together with a
+// configuration ConfigMap, a state ConfigMap (optionally carrying the given terraform_version) and a variables
+// Secret, named "configuration", "state" and "variables".
+func PrepareTestObjects(ctx context.Context, c client.Client, namePrefix, terraformVersion string) *TestObjects {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("tf-test-%s", namePrefix),
+		},
+	}
+	Expect(c.Create(ctx, namespace)).To(Succeed())
+
+	state := ""
+	if terraformVersion != "" {
+		state = fmt.Sprintf(`{"version": 4, "terraform_version": %q}`, terraformVersion)
+	}
+
+	configCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name, Name: "configuration"},
+		Data: map[string]string{
+			"main.tf": "# test configuration\n",
+		},
+	}
+	Expect(c.Create(ctx, configCM)).To(Succeed())
+
+	stateCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name, Name: "state"},
+		Data: map[string]string{
+			"terraform.tfstate": state,
+		},
+	}
+	Expect(c.Create(ctx, stateCM)).To(Succeed())
+
+	varsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace.Name, Name: "variables"},
+		Data: map[string][]byte{
+			"TF_VAR_foo": []byte("bar"),
+		},
+	}
+	Expect(c.Create(ctx, varsSecret)).To(Succeed())
+
+	var handle CleanupActionHandle
+	handle = AddCleanupAction(func() {
+		defer RemoveCleanupAction(handle)
+		Expect(client.IgnoreNotFound(c.Delete(ctx, namespace))).To(Succeed())
+	})
+
+	return &TestObjects{
+		client:                 c,
+		ctx:                    ctx,
+		Namespace:              namespace.Name,
+		ConfigurationConfigMap: configCM,
+		StateConfigMap:         stateCM,
+		VariablesSecret:        varsSecret,
+	}
+}
+
+// Refresh re-fetches ConfigurationConfigMap, StateConfigMap and VariablesSecret from the API server, so specs can
+// observe finalizers/data that Terraformer changed out-of-band.
+func (o *TestObjects) Refresh() {
+	Expect(o.client.Get(o.ctx, ObjectKeyFromObject(o.ConfigurationConfigMap), o.ConfigurationConfigMap)).To(Succeed())
+	Expect(o.client.Get(o.ctx, ObjectKeyFromObject(o.StateConfigMap), o.StateConfigMap)).To(Succeed())
+	Expect(o.client.Get(o.ctx, ObjectKeyFromObject(o.VariablesSecret), o.VariablesSecret)).To(Succeed())
+}
+
+// ObjectKeyFromObject returns the namespace/name of the given object as a client.ObjectKey.
+func ObjectKeyFromObject(obj metav1.Object) types.NamespacedName {
+	return types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}